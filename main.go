@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +21,9 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 
-	"github.com/shirou/gopsutil/disk"
+	"github.com/MarwanAyman2810/HomeMigrate/migrator"
+	"github.com/MarwanAyman2810/HomeMigrate/mtptarget"
+	"github.com/MarwanAyman2810/HomeMigrate/usbwatch"
 	"golang.org/x/sys/unix"
 )
 
@@ -52,15 +56,22 @@ func (l *uiLogger) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-type USBEvent struct {
-	path    string
-	removed bool
+type USBDrive struct {
+	name  string
+	path  string
+	size  int64 // size in bytes
+	isMTP bool  // true for an MTP-attached device rather than a block device
 }
 
-type USBDrive struct {
-	name string
-	path string
-	size int64 // size in bytes
+// usbLabel renders usb the way usbSelect's dropdown shows it, so the
+// same string can both populate the list and be matched back against
+// usbSelect.Selected.
+func usbLabel(usb USBDrive) string {
+	sizeGB := float64(usb.size) / (1024 * 1024 * 1024)
+	if usb.isMTP {
+		return fmt.Sprintf("%s (%.1f GB) [MTP]", usb.name, sizeGB)
+	}
+	return fmt.Sprintf("%s (%.1f GB)", usb.name, sizeGB)
 }
 
 func main() {
@@ -96,10 +107,26 @@ func main() {
 	uiLog := &uiLogger{textArea: logArea}
 	log.SetOutput(io.MultiWriter(uiLog, os.Stdout))
 
-	// Create a channel to receive USB detection events
-	usbChan := make(chan USBEvent)
+	// Subscribe to USB hotplug events over udev netlink instead of polling
 	var availableUSBs []USBDrive
 
+	// MTP devices (phones, tablets) don't hotplug through udev block
+	// events, so they're enumerated once up front instead.
+	if mtpDevices, err := mtptarget.List(); err != nil {
+		log.Println("MTP enumeration unavailable:", err)
+	} else {
+		for _, d := range mtpDevices {
+			availableUSBs = append(availableUSBs, USBDrive{name: d.Name, size: d.SizeBytes, isMTP: true})
+		}
+	}
+
+	watchCtx, cancelWatch := context.WithCancel(context.Background())
+	watcher := usbwatch.NewWatcher()
+	if err := watcher.Start(watchCtx); err != nil {
+		log.Println("Error starting USB watcher:", err)
+	}
+	window.SetOnClosed(cancelWatch)
+
 	// Create a dropdown to show available USB drives
 	usbSelect := widget.NewSelect([]string{}, func(selected string) {
 		if selected != "" {
@@ -109,53 +136,64 @@ func main() {
 	usbSelect.PlaceHolder = "Select USB Drive"
 	usbSelect.Hide()
 
-	// Start USB detection in background
-	go detectUSB(usbChan)
+	// refreshUSBSelect repopulates the dropdown from availableUSBs and
+	// updates the status label/visibility to match.
+	refreshUSBSelect := func() {
+		var names []string
+		for _, usb := range availableUSBs {
+			names = append(names, usbLabel(usb))
+		}
+		usbSelect.Options = names
+
+		if len(availableUSBs) > 0 {
+			status.SetText("Please select a USB drive for migration")
+			usbSelect.Show()
+		} else {
+			status.SetText("Waiting for USB drive...")
+			usbSelect.Hide()
+			usbSelect.Selected = ""
+		}
+		usbSelect.Refresh()
+	}
+	refreshUSBSelect()
+
 	fmt.Println("Started USB detection")
 
 	// Start a goroutine to handle USB events
 	go func() {
-		for event := range usbChan {
-			if event.removed {
+		for event := range watcher.Events() {
+			if event.Removed {
 				// Remove the USB from available list
 				for i, usb := range availableUSBs {
-					if usb.path == event.path {
+					if usb.path == event.Path {
 						availableUSBs = append(availableUSBs[:i], availableUSBs[i+1:]...)
 						break
 					}
 				}
-			} else if event.path != "" {
+			} else if event.Path != "" {
 				// Add new USB to available list
-				name := filepath.Base(event.path)
+				name := filepath.Base(event.Path)
 				// Get drive size
 				var stat unix.Statfs_t
-				if err := unix.Statfs(event.path, &stat); err == nil {
+				if err := unix.Statfs(event.Path, &stat); err == nil {
 					totalSize := int64(stat.Blocks) * int64(stat.Bsize)
 					availableUSBs = append(availableUSBs, USBDrive{
 						name: name,
-						path: event.path,
+						path: event.Path,
 						size: totalSize,
 					})
 				} else {
-					log.Printf("Error getting size for %s: %v", event.path, err)
+					log.Printf("Error getting size for %s: %v", event.Path, err)
 					availableUSBs = append(availableUSBs, USBDrive{
 						name: name,
-						path: event.path,
+						path: event.Path,
 						size: 0,
 					})
 				}
 			}
 
-			// Update the dropdown options
-			var names []string
-			for _, usb := range availableUSBs {
-				sizeGB := float64(usb.size) / (1024 * 1024 * 1024)
-				names = append(names, fmt.Sprintf("%s (%.1f GB)", usb.name, sizeGB))
-			}
-			usbSelect.Options = names
-
 			// If the currently selected USB was removed, clear the selection
-			if event.removed {
+			if event.Removed {
 				selectedName := usbSelect.Selected
 				found := false
 				for _, usb := range availableUSBs {
@@ -166,23 +204,55 @@ func main() {
 				}
 				if !found {
 					usbSelect.Selected = ""
-					usbSelect.Refresh()
 				}
 			}
 
-			// Update visibility and status
-			if len(availableUSBs) > 0 {
-				status.SetText("Please select a USB drive for migration")
-				usbSelect.Show()
-			} else {
-				status.SetText("Waiting for USB drive...")
-				usbSelect.Hide()
-				usbSelect.Selected = ""
-			}
-			usbSelect.Refresh()
+			refreshUSBSelect()
 		}
 	}()
 
+	// ETA/throughput label shown alongside the progress bar
+	etaLabel := widget.NewLabel("")
+
+	// Slider to control how many files are copied concurrently; defaults
+	// to the same min(NumCPU, 4) the migrator package uses on its own.
+	maxWorkers := float64(runtime.NumCPU())
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	workersLabel := widget.NewLabel(fmt.Sprintf("Parallel copies: %d", migrator.DefaultWorkers()))
+	workersSlider := widget.NewSlider(1, maxWorkers)
+	workersSlider.SetValue(float64(migrator.DefaultWorkers()))
+	workersSlider.OnChanged = func(v float64) {
+		workersLabel.SetText(fmt.Sprintf("Parallel copies: %d", int(v)))
+	}
+
+	var currentJob *migrator.Job
+
+	// Pause/resume and cancel controls for an in-flight migration
+	pauseBtn := widget.NewButton("Pause", nil)
+	cancelBtn := widget.NewButton("Cancel", nil)
+	pauseBtn.Hide()
+	cancelBtn.Hide()
+
+	pauseBtn.OnTapped = func() {
+		if currentJob == nil {
+			return
+		}
+		if pauseBtn.Text == "Pause" {
+			currentJob.Pause()
+			pauseBtn.SetText("Resume")
+		} else {
+			currentJob.Resume()
+			pauseBtn.SetText("Pause")
+		}
+	}
+	cancelBtn.OnTapped = func() {
+		if currentJob != nil {
+			currentJob.Cancel()
+		}
+	}
+
 	// Button to start migration
 	startBtn := widget.NewButton("Start Migration", func() {
 		fmt.Println("Start button clicked")
@@ -191,27 +261,103 @@ func main() {
 			return
 		}
 
-		// Find the full path for the selected name
-		var selectedPath string
-		for _, usb := range availableUSBs {
-			if usb.name == strings.Split(usbSelect.Selected, " ")[0] {
-				selectedPath = usb.path
+		// Find the selected drive by its rendered dropdown label
+		var selectedUSB *USBDrive
+		for i := range availableUSBs {
+			if usbLabel(availableUSBs[i]) == usbSelect.Selected {
+				selectedUSB = &availableUSBs[i]
 				break
 			}
 		}
+		if selectedUSB == nil {
+			dialog.ShowError(fmt.Errorf("selected drive is no longer available"), window)
+			return
+		}
+
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
 
-		progress.Show()
-		go func() {
-			err := copyHomeFolder(selectedPath, progress)
+		destHomeDir := "home_backup"
+		target := migrator.NewOSTarget()
+		if selectedUSB.isMTP {
+			mtpTarget, err := mtptarget.Open(selectedUSB.name)
 			if err != nil {
-				dialog.ShowError(err, window)
-				status.SetText("Migration failed: " + err.Error())
-			} else {
-				dialog.ShowInformation("Success", "Home folder migration completed!", window)
-				status.SetText("Migration completed successfully!")
+				dialog.ShowError(fmt.Errorf("opening MTP device: %w", err), window)
+				return
 			}
-			progress.Hide()
-		}()
+			target = mtpTarget
+		} else {
+			destHomeDir = filepath.Join(selectedUSB.path, "home_backup")
+		}
+
+		runJob := func(resume bool) {
+			job := migrator.NewJob(homeDir, destHomeDir)
+			job.Target = target
+			job.ResumeMode = resume
+			job.Workers = int(workersSlider.Value)
+			currentJob = job
+
+			pauseBtn.SetText("Pause")
+			pauseBtn.Show()
+			cancelBtn.Show()
+			progress.Show()
+			progress.SetValue(0)
+
+			go func() {
+				var migrateErr error
+				var mismatches []string
+				for p := range job.Start(context.Background()) {
+					if p.BytesTotal > 0 {
+						progress.SetValue(float64(p.BytesCopied) / float64(p.BytesTotal))
+					}
+					if p.Done {
+						migrateErr = p.Err
+						mismatches = p.Mismatches
+						continue
+					}
+					etaLabel.SetText(fmt.Sprintf("%s copied, %s/s, ETA %s",
+						formatBytes(p.BytesCopied), formatBytes(int64(p.BytesPerSec)), p.ETA.Round(time.Second)))
+				}
+
+				progress.Hide()
+				pauseBtn.Hide()
+				cancelBtn.Hide()
+				etaLabel.SetText("")
+
+				// target may be an MTP session (mtptarget.Target), which
+				// holds a libusb device handle open until closed; local
+				// directory targets have no such resource to release.
+				if closer, ok := target.(interface{ Close() }); ok {
+					closer.Close()
+				}
+
+				switch {
+				case migrateErr != nil:
+					dialog.ShowError(migrateErr, window)
+					status.SetText("Migration failed: " + migrateErr.Error())
+				case len(mismatches) > 0:
+					log.Printf("Verification found %d mismatched file(s):\n%s", len(mismatches), strings.Join(mismatches, "\n"))
+					dialog.ShowInformation("Verification warning",
+						fmt.Sprintf("Migration finished, but %d file(s) failed checksum verification. See logs for details.", len(mismatches)),
+						window)
+					status.SetText("Migration completed with verification warnings")
+				default:
+					dialog.ShowInformation("Success", "Home folder migration completed!", window)
+					status.SetText("Migration completed successfully!")
+				}
+			}()
+		}
+
+		if _, err := migrator.LoadManifest(destHomeDir, target); err == nil {
+			dialog.ShowConfirm("Resume migration?",
+				"A manifest from a previous migration was found on this drive. Resume it and skip files that already match?",
+				runJob, window)
+		} else {
+			runJob(false)
+		}
 	})
 
 	// Create main container with padding
@@ -220,6 +366,10 @@ func main() {
 		usbSelect,
 		startBtn,
 		progress,
+		etaLabel,
+		container.NewHBox(pauseBtn, cancelBtn),
+		workersLabel,
+		workersSlider,
 		widget.NewLabel("Logs:"),
 		logArea,
 	)
@@ -232,231 +382,16 @@ func main() {
 	fmt.Println("Window closed")
 }
 
-func detectUSB(usbChan chan USBEvent) {
-	var previousPartitions []string
-
-	for {
-		partitions, err := disk.Partitions(false)
-		if err != nil {
-			log.Println("Error getting disk partitions:", err)
-			continue
-		}
-
-		currentPartitions := make([]string, 0)
-		for _, partition := range partitions {
-			if strings.HasPrefix(partition.Device, "/dev/sd") {
-				currentPartitions = append(currentPartitions, partition.Mountpoint)
-			}
-		}
-
-		// Check for new USB drives
-		for _, current := range currentPartitions {
-			found := false
-			for _, previous := range previousPartitions {
-				if current == previous {
-					found = true
-					break
-				}
-			}
-			if !found {
-				// New USB drive detected
-				log.Println("New USB drive detected:", current)
-				usbChan <- USBEvent{path: current, removed: false}
-			}
-		}
-
-		// Check for removed USB drives
-		for _, previous := range previousPartitions {
-			found := false
-			for _, current := range currentPartitions {
-				if current == previous {
-					found = true
-					break
-				}
-			}
-			if !found {
-				// USB drive removed
-				log.Println("USB drive removed:", previous)
-				usbChan <- USBEvent{path: previous, removed: true}
-			}
-		}
-
-		previousPartitions = currentPartitions
-		time.Sleep(2 * time.Second)
-	}
-}
-
-func copyHomeFolder(destPath string, progress *widget.ProgressBar) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Println("Error getting home directory:", err)
-		return fmt.Errorf("error getting home directory: %v", err)
-	}
-
-	// Create the destination folder with absolute path
-	destHomeDir := filepath.Join(destPath, "home_backup")
-	absDestHomeDir, err := filepath.Abs(destHomeDir)
-	if err != nil {
-		log.Println("Error getting absolute path:", err)
-		return fmt.Errorf("error getting absolute path: %v", err)
-	}
-
-	// Count total files for progress bar
-	var totalFiles int64
-	filepath.Walk(homeDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Println("Error walking home directory:", err)
-			return nil
-		}
-		
-		// Skip hidden files and directories
-		if strings.HasPrefix(filepath.Base(path), ".") {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		
-		// Skip system and cache directories
-		if strings.Contains(path, "go/pkg/mod") || 
-		   strings.Contains(path, ".cache") || 
-		   strings.Contains(path, ".local/share") {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		
-		// Skip the backup directory
-		absPath, _ := filepath.Abs(path)
-		if strings.HasPrefix(absPath, absDestHomeDir) {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		
-		if !info.IsDir() {
-			totalFiles++
-		}
-		return nil
-	})
-
-	var copiedFiles int64
-	progress.SetValue(0)
-
-	// Create the destination folder
-	err = os.MkdirAll(destHomeDir, 0755)
-	if err != nil {
-		log.Println("Error creating destination directory:", err)
-		return fmt.Errorf("error creating destination directory: %v", err)
-	}
-
-	// Copy files
-	err = filepath.Walk(homeDir, func(srcPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Println("Error walking home directory:", err)
-			return err
-		}
-
-		// Skip hidden files and directories
-		if strings.HasPrefix(filepath.Base(srcPath), ".") {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Skip system and cache directories
-		if strings.Contains(srcPath, "go/pkg/mod") || 
-		   strings.Contains(srcPath, ".cache") || 
-		   strings.Contains(srcPath, ".local/share") {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Skip the backup directory using absolute path comparison
-		absSrcPath, _ := filepath.Abs(srcPath)
-		if strings.HasPrefix(absSrcPath, absDestHomeDir) {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Calculate relative path
-		relPath, err := filepath.Rel(homeDir, srcPath)
-		if err != nil {
-			log.Println("Error calculating relative path:", err)
-			return err
-		}
-
-		destFilePath := filepath.Join(destHomeDir, relPath)
-
-		if info.IsDir() {
-			err := os.MkdirAll(destFilePath, info.Mode())
-			if err != nil {
-				log.Printf("Error creating directory %s: %v", destFilePath, err)
-				return err
-			}
-			return nil // Skip further processing for directories
-		}
-
-		// Copy the file
-		err = copyFile(srcPath, destFilePath)
-		if err != nil {
-			log.Println("Error copying file:", err)
-			return err
-		}
-
-		copiedFiles++
-		progress.SetValue(float64(copiedFiles) / float64(totalFiles))
-		return nil
-	})
-
-	if err != nil {
-		log.Println("Error copying files:", err)
-		return fmt.Errorf("error copying files: %v", err)
-	}
-
-	return nil
-}
-
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		log.Println("Error opening source file:", err)
-		return err
-	}
-	defer sourceFile.Close()
-
-	// Create destination directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		log.Println("Error creating destination directory:", err)
-		return err
-	}
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		log.Println("Error creating destination file:", err)
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		log.Println("Error copying file:", err)
-		return err
+// formatBytes renders n bytes as a human-readable size, e.g. "4.2 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
-
-	// Preserve file permissions
-	sourceInfo, err := os.Stat(src)
-	if err != nil {
-		log.Println("Error getting source file info:", err)
-		return err
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
 	}
-
-	return os.Chmod(dst, sourceInfo.Mode())
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
@@ -0,0 +1,81 @@
+//go:build linux
+
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// fastCopyLocal tries to copy src to dst entirely in the kernel — first as
+// an O(1) copy-on-write clone via the FICLONE ioctl, then via
+// copy_file_range(2) — so large media files in a home directory don't pay
+// for a userspace read/write loop. It only applies when dst is a plain
+// local path (the caller checks j.Target is osTarget first); copied is
+// false whenever neither kernel path panned out (or j.CopyMode is
+// CopyStream), in which case the caller falls back to its own streaming
+// copy, which also computes the digest as it goes. Either fast path skips
+// that streaming hash, so the caller must hash dst itself afterwards.
+func fastCopyLocal(ctx context.Context, j *Job, src, dst string, info os.FileInfo) (copied bool, err error) {
+	if j.CopyMode == CopyStream {
+		return false, nil
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return false, err
+	}
+	defer dstFile.Close()
+
+	if cloneErr := unix.IoctlFileClone(int(dstFile.Fd()), int(srcFile.Fd())); cloneErr == nil {
+		atomic.AddInt64(&j.bytesCopied, info.Size())
+		return true, nil
+	} else if j.CopyMode == CopyReflink {
+		return false, fmt.Errorf("reflink copy of %s: %w", src, cloneErr)
+	}
+
+	if err := copyFileRangeCtx(ctx, j, dstFile, srcFile, info.Size()); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// copyFileRangeCtx copies size bytes from src to dst via copy_file_range(2)
+// in copyChunkSize pieces, checking ctx (and pausing) between each so
+// Cancel/Pause behave the same as they do on the userspace fallback path.
+func copyFileRangeCtx(ctx context.Context, j *Job, dst, src *os.File, size int64) error {
+	remaining := size
+	for remaining > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := j.waitIfPaused(ctx); err != nil {
+			return err
+		}
+
+		chunk := int64(copyChunkSize)
+		if remaining < chunk {
+			chunk = remaining
+		}
+		n, err := unix.CopyFileRange(int(src.Fd()), nil, int(dst.Fd()), nil, int(chunk), 0)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("copy_file_range left %d bytes uncopied", remaining)
+		}
+		atomic.AddInt64(&j.bytesCopied, int64(n))
+		remaining -= int64(n)
+	}
+	return nil
+}
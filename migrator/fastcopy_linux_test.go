@@ -0,0 +1,137 @@
+//go:build linux
+
+package migrator
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSrcFile(t *testing.T, dir, name, contents string) (path string, info os.FileInfo) {
+	t.Helper()
+	path = filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	return path, info
+}
+
+func TestCopyFileCtxCopyModeAutoFallsBackToCopyFileRange(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	srcPath, info := writeSrcFile(t, src, "a.txt", "hello, fast copy")
+
+	j := &Job{Target: NewOSTarget(), CopyMode: CopyAuto}
+	dstPath := filepath.Join(dst, "a.txt")
+
+	digest, err := j.copyFileCtx(context.Background(), srcPath, dstPath, info)
+	if err != nil {
+		t.Fatalf("copyFileCtx: %v", err)
+	}
+
+	data, err := os.ReadFile(dstPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "hello, fast copy" {
+		t.Fatalf("unexpected contents: %q", data)
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		t.Fatalf("expected a hex digest, got %q: %v", digest, err)
+	}
+}
+
+func TestCopyFileCtxCopyModeStreamSkipsFastPaths(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	srcPath, info := writeSrcFile(t, src, "a.txt", "hello, streamed")
+
+	j := &Job{Target: NewOSTarget(), CopyMode: CopyStream}
+	dstPath := filepath.Join(dst, "a.txt")
+
+	if _, err := j.copyFileCtx(context.Background(), srcPath, dstPath, info); err != nil {
+		t.Fatalf("copyFileCtx: %v", err)
+	}
+
+	data, err := os.ReadFile(dstPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "hello, streamed" {
+		t.Fatalf("unexpected contents: %q", data)
+	}
+}
+
+func TestCopyFileCtxCopyModeReflinkErrorsWhenUnsupported(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	srcPath, info := writeSrcFile(t, src, "a.txt", "hello, reflink")
+
+	// t.TempDir() typically lands on a filesystem (tmpfs/ext4 in CI)
+	// that doesn't support FICLONE, so forcing CopyReflink should fail
+	// rather than silently falling back to another path.
+	j := &Job{Target: NewOSTarget(), CopyMode: CopyReflink}
+	dstPath := filepath.Join(dst, "a.txt")
+
+	if _, err := j.copyFileCtx(context.Background(), srcPath, dstPath, info); err == nil {
+		t.Skip("filesystem under t.TempDir() supports reflinks; nothing to assert")
+	}
+
+	if _, err := os.Stat(dstPath); !os.IsNotExist(err) {
+		t.Fatalf("expected failed reflink copy to remove the partial dst, got err=%v", err)
+	}
+}
+
+func TestCopyFileRangeCtxCopiesInChunks(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	// Bigger than copyChunkSize so copyFileRangeCtx's loop runs more than
+	// once.
+	contents := make([]byte, copyChunkSize+1024)
+	for i := range contents {
+		contents[i] = byte(i)
+	}
+	srcPath := filepath.Join(src, "big.bin")
+	if err := os.WriteFile(srcPath, contents, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer srcFile.Close()
+
+	dstPath := filepath.Join(dst, "big.bin")
+	dstFile, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer dstFile.Close()
+
+	j := &Job{}
+	if err := copyFileRangeCtx(context.Background(), j, dstFile, srcFile, int64(len(contents))); err != nil {
+		t.Skipf("copy_file_range unsupported on this filesystem: %v", err)
+	}
+
+	got, err := os.ReadFile(dstPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(got) != len(contents) {
+		t.Fatalf("expected %d bytes copied, got %d", len(contents), len(got))
+	}
+	for i := range contents {
+		if got[i] != contents[i] {
+			t.Fatalf("content mismatch at byte %d", i)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package migrator
+
+import (
+	"context"
+	"os"
+)
+
+// fastCopyLocal has no kernel-side fast path outside Linux; the caller
+// always falls back to its streaming copy.
+func fastCopyLocal(ctx context.Context, j *Job, src, dst string, info os.FileInfo) (copied bool, err error) {
+	return false, nil
+}
@@ -0,0 +1,179 @@
+package migrator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ToolVersion is recorded in every manifest so a later run of HomeMigrate
+// can tell which version produced it.
+const ToolVersion = "0.1.0"
+
+// ManifestName is the filename of the JSON manifest written at the root
+// of a migration's destination directory.
+const ManifestName = "MANIFEST.json"
+
+// ManifestTextName is the filename of the human-readable companion to
+// ManifestName.
+const ManifestTextName = "MANIFEST.txt"
+
+// ManifestEntry describes one file planned for (or copied during) a
+// migration. Digest is filled in as the file is copied, or carried over
+// from the previous manifest when a Resume run skips the file, and is
+// empty for entries that haven't been processed.
+type ManifestEntry struct {
+	Path    string      `json:"path"` // relative to SrcDir/DstDir
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	Digest  string      `json:"digest,omitempty"` // hex SHA-256
+}
+
+// Manifest records the plan and outcome of a single migration so the copy
+// can be verified afterwards and, if interrupted, resumed later.
+type Manifest struct {
+	Host        string           `json:"host"`
+	User        string           `json:"user"`
+	Timestamp   time.Time        `json:"timestamp"`
+	ToolVersion string           `json:"tool_version"`
+	Entries     []*ManifestEntry `json:"entries"`
+
+	byPath map[string]*ManifestEntry
+}
+
+// newManifest builds a Manifest describing entries, stamped with the
+// current host, user and time.
+func newManifest(entries []*ManifestEntry) *Manifest {
+	host, _ := os.Hostname()
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	m := &Manifest{
+		Host:        host,
+		User:        username,
+		Timestamp:   time.Now(),
+		ToolVersion: ToolVersion,
+		Entries:     entries,
+	}
+	m.reindex()
+	return m
+}
+
+// reindex (re)builds the byPath lookup used by Lookup.
+func (m *Manifest) reindex() {
+	m.byPath = make(map[string]*ManifestEntry, len(m.Entries))
+	for _, e := range m.Entries {
+		m.byPath[e.Path] = e
+	}
+}
+
+// Lookup returns the entry for relPath, if present.
+func (m *Manifest) Lookup(relPath string) (*ManifestEntry, bool) {
+	if m == nil {
+		return nil, false
+	}
+	e, ok := m.byPath[relPath]
+	return e, ok
+}
+
+// Write persists both MANIFEST.json and its human-readable companion
+// MANIFEST.txt at the root of dir, through target.
+func (m *Manifest) Write(dir string, target Target) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileVia(target, filepath.Join(dir, ManifestName), data); err != nil {
+		return err
+	}
+	return writeFileVia(target, filepath.Join(dir, ManifestTextName), []byte(m.text()))
+}
+
+func writeFileVia(target Target, path string, data []byte) error {
+	w, err := target.Create(path)
+	if err != nil {
+		return err
+	}
+	_, writeErr := w.Write(data)
+	closeErr := w.Close()
+	if writeErr != nil {
+		return writeErr
+	}
+	return closeErr
+}
+
+func (m *Manifest) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HomeMigrate manifest\n")
+	fmt.Fprintf(&b, "host: %s\nuser: %s\ntimestamp: %s\ntool version: %s\n\n",
+		m.Host, m.User, m.Timestamp.Format(time.RFC3339), m.ToolVersion)
+	for _, e := range m.Entries {
+		fmt.Fprintf(&b, "%-60s %12d %v %s %s\n",
+			e.Path, e.Size, e.Mode, e.ModTime.Format(time.RFC3339), e.Digest)
+	}
+	return b.String()
+}
+
+// LoadManifest reads MANIFEST.json from the root of dir via target.
+func LoadManifest(dir string, target Target) (*Manifest, error) {
+	r, err := target.Open(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m.reindex()
+	return &m, nil
+}
+
+// VerifyManifest re-reads every file m describes from dstDir via target,
+// hashes it, and compares the result against the digest recorded during
+// copy. It returns one description per path that is missing or whose
+// digest doesn't match; a nil slice means every file verified cleanly.
+func VerifyManifest(dstDir string, m *Manifest, target Target) ([]string, error) {
+	var mismatches []string
+	for _, e := range m.Entries {
+		digest, err := sha256Via(target, filepath.Join(dstDir, e.Path))
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", e.Path, err))
+			continue
+		}
+		if digest != e.Digest {
+			mismatches = append(mismatches, fmt.Sprintf("%s: digest mismatch", e.Path))
+		}
+	}
+	return mismatches, nil
+}
+
+// sha256Via returns the hex-encoded SHA-256 digest of the file at path,
+// read through target.
+func sha256Via(target Target, path string) (string, error) {
+	f, err := target.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
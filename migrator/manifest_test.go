@@ -0,0 +1,88 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestWriteLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	entries := []*ManifestEntry{
+		{Path: "docs/a.txt", Size: 3, Mode: 0644, ModTime: time.Unix(1700000000, 0), Digest: "abc123"},
+	}
+	m := newManifest(entries)
+
+	target := NewOSTarget()
+	if err := m.Write(dir, target); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, name := range []string{ManifestName, ManifestTextName} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+	}
+
+	loaded, err := LoadManifest(dir, target)
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	entry, ok := loaded.Lookup("docs/a.txt")
+	if !ok {
+		t.Fatal("expected docs/a.txt to be found after reload")
+	}
+	if entry.Size != 3 || entry.Digest != "abc123" {
+		t.Fatalf("unexpected entry after reload: %+v", entry)
+	}
+}
+
+func TestSkippableRequiresMatchingDigest(t *testing.T) {
+	dir := t.TempDir()
+	dstPath := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(dstPath, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	digest, err := sha256Via(NewOSTarget(), dstPath)
+	if err != nil {
+		t.Fatalf("sha256Via: %v", err)
+	}
+
+	mtime := time.Unix(1700000000, 0)
+	entry := &ManifestEntry{Path: "a.txt", Size: 5, ModTime: mtime}
+	existing := newManifest([]*ManifestEntry{{Path: "a.txt", Size: 5, ModTime: mtime, Digest: digest}})
+
+	j := &Job{Target: NewOSTarget()}
+
+	if _, ok := j.skippable(entry, existing, dstPath); !ok {
+		t.Fatal("expected matching size/mtime/digest to be skippable")
+	}
+
+	corrupt := &ManifestEntry{Path: "a.txt", Size: 5, ModTime: mtime}
+	staleManifest := newManifest([]*ManifestEntry{{Path: "a.txt", Size: 5, ModTime: mtime, Digest: "deadbeef"}})
+	if _, ok := j.skippable(corrupt, staleManifest, dstPath); ok {
+		t.Fatal("expected digest mismatch to not be skippable")
+	}
+
+	if _, ok := j.skippable(entry, nil, dstPath); ok {
+		t.Fatal("expected nil existing manifest to never be skippable")
+	}
+}
+
+func TestVerifyManifestDetectsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	m := newManifest([]*ManifestEntry{{Path: "a.txt", Size: 5, Digest: "wrong-digest"}})
+	mismatches, err := VerifyManifest(dir, m, NewOSTarget())
+	if err != nil {
+		t.Fatalf("VerifyManifest: %v", err)
+	}
+	if len(mismatches) != 1 {
+		t.Fatalf("expected 1 mismatch, got %v", mismatches)
+	}
+}
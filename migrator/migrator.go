@@ -0,0 +1,565 @@
+// Package migrator runs a home-folder copy as a cancellable, pausable
+// background Job and reports its progress on a channel.
+package migrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// progressInterval is how often Progress snapshots are emitted. Emitting
+// at ~10 Hz lets the UI goroutine coalesce updates instead of redrawing
+// once per file.
+const progressInterval = 100 * time.Millisecond
+
+// copyChunkSize bounds how much of a single file is copied between
+// cancellation checks, so Cancel takes effect within ~4 MiB even in the
+// middle of a large file.
+const copyChunkSize = 4 * 1024 * 1024
+
+// bpsWindow is the moving-average window used to smooth BytesPerSec.
+const bpsWindow = 5 * time.Second
+
+// Progress is a snapshot of an in-flight Job.
+type Progress struct {
+	FilesCopied int64
+	FilesTotal  int64
+	BytesCopied int64
+	BytesTotal  int64
+	CurrentPath string
+	BytesPerSec float64
+	ETA         time.Duration
+	// Mismatches lists files that failed post-copy manifest verification.
+	// It is only populated on the final, Done Progress.
+	Mismatches []string
+	Err        error
+	Done       bool
+}
+
+// CopyMode selects the fast-path strategy copyFileCtx uses for a local
+// Target.
+type CopyMode int
+
+const (
+	// CopyAuto tries the fastest kernel-side copy available (reflink,
+	// then copy_file_range) and falls back to a streaming copy when
+	// neither is supported.
+	CopyAuto CopyMode = iota
+	// CopyReflink forces a copy-on-write clone via FICLONE and fails if
+	// the source and destination filesystem don't support it.
+	CopyReflink
+	// CopyStream forces a plain streaming copy, skipping any kernel-side
+	// fast paths. Useful for tests and for filesystems known not to
+	// support reflinks.
+	CopyStream
+)
+
+// Job copies SrcDir into DstDir, and can be paused, resumed or cancelled
+// while in flight.
+type Job struct {
+	SrcDir string
+	DstDir string
+
+	// ResumeMode, if true, makes Start look for an existing MANIFEST.json
+	// in DstDir and skip any file whose size, mtime and on-disk digest
+	// still match it. Named ResumeMode rather than Resume so it doesn't
+	// collide with the Resume method that unpauses a paused Job.
+	ResumeMode bool
+
+	// Workers caps how many files are copied concurrently. Zero means
+	// DefaultWorkers(). It is ignored (forced to 1) when DstDir resolves
+	// to a rotational block device, to avoid seek thrashing.
+	Workers int
+
+	// Target is where DstDir is written. Defaults to NewOSTarget() — a
+	// plain local directory — so existing callers don't need to know
+	// Target exists.
+	Target Target
+
+	// CopyMode selects how copyFileCtx copies a file when Target is a
+	// local directory. Zero value is CopyAuto.
+	CopyMode CopyMode
+
+	filesCopied int64
+	bytesCopied int64
+
+	mu       sync.Mutex
+	curPath  string
+	paused   bool
+	resumeCh chan struct{}
+	cancel   context.CancelFunc
+}
+
+// NewJob returns a Job that will copy srcDir into dstDir on the local
+// filesystem. Set Target before calling Start to write somewhere else,
+// such as an MTP device.
+func NewJob(srcDir, dstDir string) *Job {
+	return &Job{SrcDir: srcDir, DstDir: dstDir, Target: NewOSTarget(), resumeCh: make(chan struct{})}
+}
+
+// Start pre-walks SrcDir to size the job, then begins copying in a
+// background goroutine. Progress snapshots are sent on the returned
+// channel roughly every progressInterval; it is closed once the job
+// finishes, fails, or ctx is cancelled.
+func (j *Job) Start(ctx context.Context) <-chan Progress {
+	runCtx, cancel := context.WithCancel(ctx)
+	j.cancel = cancel
+
+	out := make(chan Progress, 1)
+	go j.run(runCtx, out)
+	return out
+}
+
+// Pause blocks the copy loop before its next file (and within the
+// current file at the next copyChunkSize boundary).
+func (j *Job) Pause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.paused = true
+}
+
+// Resume unblocks a paused copy loop.
+func (j *Job) Resume() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.paused {
+		j.paused = false
+		close(j.resumeCh)
+		j.resumeCh = make(chan struct{})
+	}
+}
+
+// Cancel stops the job. The file being written at the time is deleted so
+// no partial file is left behind.
+func (j *Job) Cancel() {
+	if j.cancel != nil {
+		j.cancel()
+	}
+}
+
+// waitIfPaused blocks while the job is paused, returning early if ctx is
+// cancelled.
+func (j *Job) waitIfPaused(ctx context.Context) error {
+	for {
+		j.mu.Lock()
+		paused := j.paused
+		resumeCh := j.resumeCh
+		j.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		select {
+		case <-resumeCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (j *Job) run(ctx context.Context, out chan<- Progress) {
+	defer close(out)
+
+	entries, bytesTotal, err := j.preWalk()
+	if err != nil {
+		out <- Progress{Err: err, Done: true}
+		return
+	}
+	filesTotal := int64(len(entries))
+	manifest := newManifest(entries)
+
+	var existing *Manifest
+	if j.ResumeMode {
+		existing, _ = LoadManifest(j.DstDir, j.Target)
+	}
+
+	if err := j.Target.MkdirAll(j.DstDir, 0755); err != nil {
+		out <- Progress{Err: err, Done: true}
+		return
+	}
+	if err := manifest.Write(j.DstDir, j.Target); err != nil {
+		out <- Progress{Err: err, Done: true}
+		return
+	}
+
+	tickerDone := make(chan struct{})
+	go j.emitProgress(ctx, filesTotal, bytesTotal, tickerDone, out)
+
+	err = j.copyTree(ctx, manifest, existing)
+	close(tickerDone)
+
+	var mismatches []string
+	if err == nil {
+		if werr := manifest.Write(j.DstDir, j.Target); werr != nil {
+			err = werr
+		} else {
+			mismatches, err = VerifyManifest(j.DstDir, manifest, j.Target)
+		}
+	}
+
+	p := j.snapshot(filesTotal, bytesTotal, 0, true, err)
+	p.Mismatches = mismatches
+	out <- p
+}
+
+// preWalk collects every file under SrcDir up front, as manifest entries,
+// so the progress bar can advance by bytes rather than file count and the
+// manifest can be written before the copy begins.
+func (j *Job) preWalk() (entries []*ManifestEntry, bytesTotal int64, err error) {
+	absDst, err := filepath.Abs(j.DstDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = filepath.Walk(j.SrcDir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return nil
+		}
+		if skip, skipDir := j.shouldSkip(path, info, absDst); skip {
+			if skipDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(j.SrcDir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &ManifestEntry{
+			Path:    relPath,
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		})
+		bytesTotal += info.Size()
+		return nil
+	})
+	return entries, bytesTotal, err
+}
+
+func (j *Job) shouldSkip(path string, info os.FileInfo, absDst string) (skip, isDir bool) {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return true, info.IsDir()
+	}
+	if strings.Contains(path, "go/pkg/mod") || strings.Contains(path, ".cache") || strings.Contains(path, ".local/share") {
+		return true, info.IsDir()
+	}
+	absPath, _ := filepath.Abs(path)
+	if strings.HasPrefix(absPath, absDst) {
+		return true, info.IsDir()
+	}
+	return false, false
+}
+
+// DefaultWorkers returns the default copy concurrency: up to 4 CPUs,
+// since beyond that small-file copies become I/O- rather than CPU-bound.
+func DefaultWorkers() int {
+	if n := runtime.NumCPU(); n < 4 {
+		return n
+	}
+	return 4
+}
+
+// copyTask is one file handed from the walker goroutine to a worker.
+type copyTask struct {
+	srcPath string
+	relPath string
+	info    os.FileInfo
+}
+
+// copyTree walks SrcDir in a single producer goroutine — which also
+// creates every destination directory, serialized and ahead of any file
+// inside it, so workers never race on MkdirAll — and fans file copies out
+// to a pool of worker goroutines. The pool is forced to 1 when DstDir
+// sits on a rotational disk, where concurrent copies just cause seeking.
+func (j *Job) copyTree(ctx context.Context, manifest, existing *Manifest) error {
+	absDst, err := filepath.Abs(j.DstDir)
+	if err != nil {
+		return err
+	}
+
+	workers := j.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers()
+	}
+	if isRotational(j.DstDir) {
+		workers = 1
+	}
+
+	tasks := make(chan copyTask, workers*2)
+	g, gctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for t := range tasks {
+				if err := j.copyOne(gctx, t, manifest, existing); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	walkErr := filepath.Walk(j.SrcDir, func(srcPath string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if skip, skipDir := j.shouldSkip(srcPath, info, absDst); skip {
+			if skipDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := gctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(j.SrcDir, srcPath)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return j.Target.MkdirAll(filepath.Join(j.DstDir, relPath), info.Mode())
+		}
+
+		select {
+		case tasks <- copyTask{srcPath: srcPath, relPath: relPath, info: info}:
+			return nil
+		case <-gctx.Done():
+			return gctx.Err()
+		}
+	})
+	close(tasks)
+
+	if groupErr := g.Wait(); groupErr != nil {
+		return groupErr
+	}
+	return walkErr
+}
+
+// copyOne copies (or skips, on Resume) the single file described by t.
+func (j *Job) copyOne(ctx context.Context, t copyTask, manifest, existing *Manifest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := j.waitIfPaused(ctx); err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.curPath = t.relPath
+	j.mu.Unlock()
+
+	dstPath := filepath.Join(j.DstDir, t.relPath)
+	// entry can be nil: preWalk and this walk are two separate passes
+	// over a live home directory, so a file created in between (a
+	// browser cache entry, a fresh download) won't be in manifest. It's
+	// still copied, just not tracked in the manifest or resume-skippable.
+	entry, _ := manifest.Lookup(t.relPath)
+
+	if prev, ok := j.skippable(entry, existing, dstPath); ok {
+		entry.Digest = prev.Digest
+		atomic.AddInt64(&j.filesCopied, 1)
+		atomic.AddInt64(&j.bytesCopied, entry.Size)
+		return nil
+	}
+
+	digest, err := j.copyFileCtx(ctx, t.srcPath, dstPath, t.info)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		entry.Digest = digest
+	}
+
+	atomic.AddInt64(&j.filesCopied, 1)
+	return nil
+}
+
+// skippable reports whether entry can be satisfied by a file already at
+// dstPath from a previous, interrupted run: existing must describe the
+// same size and mtime, and the file on disk must still hash to the
+// digest existing recorded.
+func (j *Job) skippable(entry *ManifestEntry, existing *Manifest, dstPath string) (*ManifestEntry, bool) {
+	if existing == nil || entry == nil {
+		return nil, false
+	}
+	prev, ok := existing.Lookup(entry.Path)
+	if !ok || prev.Digest == "" || prev.Size != entry.Size || !prev.ModTime.Equal(entry.ModTime) {
+		return nil, false
+	}
+	if digest, err := sha256Via(j.Target, dstPath); err != nil || digest != prev.Digest {
+		return nil, false
+	}
+	return prev, true
+}
+
+// copyFileCtx copies src to dst, checking ctx between chunks so Cancel
+// takes effect almost immediately, and returns dst's SHA-256 digest. dst is
+// written through j.Target, so this works the same whether Target is a
+// local directory or something like an MTP device — but when it is a local
+// directory, it first tries fastCopyLocal's kernel-side reflink/
+// copy_file_range paths, since those are the common case for this tool
+// (large media files in a home directory, usually on the same filesystem)
+// and avoid a userspace read/write loop entirely; the digest is then
+// computed with a separate hashing pass, since both fast paths bypass the
+// streaming hasher the generic path uses. On failure (including
+// cancellation) the partially written dst is removed.
+func (j *Job) copyFileCtx(ctx context.Context, src, dst string, info os.FileInfo) (string, error) {
+	if _, ok := j.Target.(osTarget); ok {
+		if err := j.Target.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return "", err
+		}
+		switch copied, err := fastCopyLocal(ctx, j, src, dst, info); {
+		case err != nil:
+			j.Target.Remove(dst)
+			return "", err
+		case copied:
+			digest, err := sha256Via(j.Target, dst)
+			if err != nil {
+				return "", err
+			}
+			if err := j.Target.Chmod(dst, info.Mode()); err != nil {
+				return "", err
+			}
+			return digest, nil
+		}
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer srcFile.Close()
+
+	if err := j.Target.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	dstFile, err := j.Target.Create(dst)
+	if err != nil {
+		return "", err
+	}
+
+	reader := &ctxReader{ctx: ctx, r: srcFile, pausable: j}
+	hasher := sha256.New()
+	writer := &countingWriter{w: io.MultiWriter(dstFile, hasher), counter: &j.bytesCopied}
+
+	buf := make([]byte, copyChunkSize)
+	_, copyErr := io.CopyBuffer(writer, reader, buf)
+	closeErr := dstFile.Close()
+
+	if copyErr != nil || closeErr != nil {
+		j.Target.Remove(dst)
+		if copyErr != nil {
+			return "", copyErr
+		}
+		return "", closeErr
+	}
+
+	if err := j.Target.Chmod(dst, info.Mode()); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (j *Job) emitProgress(ctx context.Context, filesTotal, bytesTotal int64, done <-chan struct{}, out chan<- Progress) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	var bytesPerSec float64
+	lastBytes := atomic.LoadInt64(&j.bytesCopied)
+	lastTick := time.Now()
+	alpha := float64(progressInterval) / float64(bpsWindow)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			bytes := atomic.LoadInt64(&j.bytesCopied)
+			elapsed := now.Sub(lastTick).Seconds()
+			instant := 0.0
+			if elapsed > 0 {
+				instant = float64(bytes-lastBytes) / elapsed
+			}
+			bytesPerSec = alpha*instant + (1-alpha)*bytesPerSec
+			lastBytes, lastTick = bytes, now
+
+			out <- j.snapshot(filesTotal, bytesTotal, bytesPerSec, false, nil)
+		}
+	}
+}
+
+func (j *Job) snapshot(filesTotal, bytesTotal int64, bytesPerSec float64, done bool, err error) Progress {
+	bytesCopied := atomic.LoadInt64(&j.bytesCopied)
+
+	var eta time.Duration
+	if bytesPerSec > 0 && bytesTotal > bytesCopied {
+		eta = time.Duration(float64(bytesTotal-bytesCopied)/bytesPerSec) * time.Second
+	}
+
+	j.mu.Lock()
+	curPath := j.curPath
+	j.mu.Unlock()
+
+	return Progress{
+		FilesCopied: atomic.LoadInt64(&j.filesCopied),
+		FilesTotal:  filesTotal,
+		BytesCopied: bytesCopied,
+		BytesTotal:  bytesTotal,
+		CurrentPath: curPath,
+		BytesPerSec: bytesPerSec,
+		ETA:         eta,
+		Err:         err,
+		Done:        done,
+	}
+}
+
+// ctxReader wraps an io.Reader so Read returns ctx.Err() once ctx is
+// cancelled, and blocks while the owning Job is paused.
+type ctxReader struct {
+	ctx      context.Context
+	r        io.Reader
+	pausable *Job
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := c.pausable.waitIfPaused(c.ctx); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// countingWriter tallies bytes written through it in an atomic counter so
+// progress can be read concurrently from the emitter goroutine.
+type countingWriter struct {
+	w       io.Writer
+	counter *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.counter, int64(n))
+	return n, err
+}
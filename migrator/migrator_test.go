@@ -0,0 +1,154 @@
+package migrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobCopiesTreeAndWritesVerifiedManifest(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "home_backup")
+
+	if err := os.MkdirAll(filepath.Join(src, "docs"), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "docs", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile a.txt: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "b.txt"), []byte("world"), 0644); err != nil {
+		t.Fatalf("WriteFile b.txt: %v", err)
+	}
+
+	job := NewJob(src, dst)
+	job.Workers = 2
+
+	var final Progress
+	for p := range job.Start(context.Background()) {
+		if p.Done {
+			final = p
+		}
+	}
+
+	if final.Err != nil {
+		t.Fatalf("job failed: %v", final.Err)
+	}
+	if len(final.Mismatches) != 0 {
+		t.Fatalf("unexpected verification mismatches: %v", final.Mismatches)
+	}
+	if final.FilesCopied != 2 {
+		t.Fatalf("expected 2 files copied, got %d", final.FilesCopied)
+	}
+
+	for _, rel := range []string{"docs/a.txt", "b.txt"} {
+		data, err := os.ReadFile(filepath.Join(dst, rel))
+		if err != nil {
+			t.Fatalf("ReadFile %s: %v", rel, err)
+		}
+		if len(data) == 0 {
+			t.Fatalf("%s copied empty", rel)
+		}
+	}
+
+	m, err := LoadManifest(dst, NewOSTarget())
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	entry, ok := m.Lookup("docs/a.txt")
+	if !ok || entry.Digest == "" {
+		t.Fatalf("expected docs/a.txt to have a recorded digest, got %+v", entry)
+	}
+}
+
+func TestJobResumeSkipsMatchingFiles(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "home_backup")
+
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	first := NewJob(src, dst)
+	for p := range first.Start(context.Background()) {
+		if p.Done && p.Err != nil {
+			t.Fatalf("first run failed: %v", p.Err)
+		}
+	}
+
+	second := NewJob(src, dst)
+	second.ResumeMode = true
+	var final Progress
+	for p := range second.Start(context.Background()) {
+		if p.Done {
+			final = p
+		}
+	}
+	if final.Err != nil {
+		t.Fatalf("resumed run failed: %v", final.Err)
+	}
+	if final.FilesCopied != 1 {
+		t.Fatalf("expected the resumed run to still account for 1 file, got %d", final.FilesCopied)
+	}
+}
+
+func TestJobCancelStillSendsDoneProgress(t *testing.T) {
+	src := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "home_backup")
+
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // already cancelled before Start
+
+	job := NewJob(src, dst)
+	var final Progress
+	var sawDone bool
+	for p := range job.Start(ctx) {
+		if p.Done {
+			sawDone = true
+			final = p
+		}
+	}
+	if !sawDone {
+		t.Fatal("expected a Done progress snapshot even though the job was cancelled")
+	}
+	if final.Err == nil {
+		t.Fatal("expected the Done snapshot to carry the cancellation error")
+	}
+}
+
+func TestCopyOneHandlesFileMissingFromManifest(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "late.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// Simulates a file that showed up between preWalk and this walk: it
+	// has no entry in manifest at all.
+	manifest := newManifest(nil)
+	j := &Job{SrcDir: src, DstDir: dst, Target: NewOSTarget()}
+
+	task := copyTask{srcPath: filepath.Join(src, "late.txt"), relPath: "late.txt"}
+	info, err := os.Stat(task.srcPath)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	task.info = info
+
+	if err := j.copyOne(context.Background(), task, manifest, nil); err != nil {
+		t.Fatalf("copyOne: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "late.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("unexpected contents: %q", data)
+	}
+}
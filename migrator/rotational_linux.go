@@ -0,0 +1,40 @@
+//go:build linux
+
+package migrator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// isRotational reports whether the block device backing dir is a
+// spinning disk rather than flash, by resolving dir's underlying device
+// via stat(2) and walking up its sysfs device directory until it finds
+// queue/rotational — present on the whole-disk device, not on a
+// partition's own directory. Any failure (non-Linux mount, missing
+// sysfs, etc.) is treated as non-rotational.
+func isRotational(dir string) bool {
+	var stat unix.Stat_t
+	if err := unix.Stat(dir, &stat); err != nil {
+		return false
+	}
+	major := unix.Major(stat.Dev)
+	minor := unix.Minor(stat.Dev)
+
+	devPath, err := filepath.EvalSymlinks(fmt.Sprintf("/sys/dev/block/%d:%d", major, minor))
+	if err != nil {
+		return false
+	}
+
+	for p := devPath; p != "/" && p != "."; p = filepath.Dir(p) {
+		data, err := os.ReadFile(filepath.Join(p, "queue", "rotational"))
+		if err == nil {
+			return strings.TrimSpace(string(data)) == "1"
+		}
+	}
+	return false
+}
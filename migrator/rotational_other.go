@@ -0,0 +1,10 @@
+//go:build !linux
+
+package migrator
+
+// isRotational always reports false on non-Linux platforms: the
+// /sys/block/<dev>/queue/rotational check it's based on is Linux-only, so
+// the worker pool is left at its configured size.
+func isRotational(dir string) bool {
+	return false
+}
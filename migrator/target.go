@@ -0,0 +1,50 @@
+package migrator
+
+import (
+	"io"
+	"os"
+)
+
+// Target abstracts the destination side of a migration so the same copy
+// pipeline can write to a plain local directory (osTarget, the default)
+// or to a device that isn't a real filesystem, such as an MTP-attached
+// phone (mtptarget.Target, behind the "mtp" build tag).
+type Target interface {
+	// MkdirAll ensures every directory component of path exists.
+	MkdirAll(path string, perm os.FileMode) error
+	// Create truncates, or creates, the file at path for writing.
+	Create(path string) (io.WriteCloser, error)
+	// Open opens path for reading, used when re-hashing a file already on
+	// the target during verification or a Resume skip check.
+	Open(path string) (io.ReadCloser, error)
+	// Stat returns file info for path.
+	Stat(path string) (os.FileInfo, error)
+	// Chmod sets path's mode. Targets without a real mode concept may
+	// treat this as a no-op.
+	Chmod(path string, mode os.FileMode) error
+	// Remove deletes path, used to clean up a partial write on cancel or
+	// error.
+	Remove(path string) error
+}
+
+// osTarget implements Target on a plain local directory tree — the only
+// behavior a Job had before Target existed.
+type osTarget struct{}
+
+// NewOSTarget returns a Target that reads and writes a local directory
+// tree with the regular os package. It's the default for every Job.
+func NewOSTarget() Target { return osTarget{} }
+
+func (osTarget) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (osTarget) Create(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+func (osTarget) Open(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+func (osTarget) Stat(path string) (os.FileInfo, error) { return os.Stat(path) }
+
+func (osTarget) Chmod(path string, mode os.FileMode) error { return os.Chmod(path, mode) }
+
+func (osTarget) Remove(path string) error { return os.Remove(path) }
@@ -0,0 +1,23 @@
+// Package mtptarget lets a migration read or write an MTP-attached
+// device — phones, tablets — as a migrator.Target instead of a plain
+// local directory. The real implementation talks to libusb via
+// github.com/hanwen/go-mtpfs/mtp and is only compiled in with the "mtp"
+// build tag, since that dependency is non-trivial to ship everywhere;
+// without the tag, List and Open just report that MTP isn't available.
+package mtptarget
+
+import "fmt"
+
+// Device describes one MTP device/storage pairing discovered by List,
+// e.g. a phone's internal storage.
+type Device struct {
+	Name      string
+	SizeBytes int64
+}
+
+// String renders d the way HomeMigrate's USB dropdown expects, tagged so
+// it reads as distinct from a block-device entry, e.g.
+// "Internal shared storage (58.2 GB) [MTP]".
+func (d Device) String() string {
+	return fmt.Sprintf("%s (%.1f GB) [MTP]", d.Name, float64(d.SizeBytes)/(1<<30))
+}
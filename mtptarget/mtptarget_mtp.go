@@ -0,0 +1,274 @@
+//go:build mtp
+
+package mtptarget
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	gomtp "github.com/hanwen/go-mtpfs/mtp"
+)
+
+// List enumerates every MTP device attached to the system, opens a
+// session on each long enough to read its storages, and returns one
+// Device per storage found.
+func List() ([]Device, error) {
+	raws, err := gomtp.FindDevices()
+	if err != nil {
+		return nil, fmt.Errorf("mtptarget: enumerating devices: %w", err)
+	}
+
+	var devices []Device
+	for _, raw := range raws {
+		dev, err := raw.Open()
+		if err != nil {
+			continue
+		}
+		if err := dev.Configure(); err == nil {
+			if storageIDs, err := dev.GetStorageIDs(); err == nil {
+				for _, id := range storageIDs.IDs {
+					if info, err := dev.GetStorageInfo(id); err == nil {
+						devices = append(devices, Device{
+							Name:      info.StorageDescription,
+							SizeBytes: int64(info.MaxCapacity),
+						})
+					}
+				}
+			}
+		}
+		dev.Close()
+	}
+	return devices, nil
+}
+
+// Open starts a session against the device/storage whose name matches
+// name (as returned by List) and returns it as a migrator.Target.
+func Open(name string) (*Target, error) {
+	raws, err := gomtp.FindDevices()
+	if err != nil {
+		return nil, fmt.Errorf("mtptarget: enumerating devices: %w", err)
+	}
+
+	for _, raw := range raws {
+		dev, err := raw.Open()
+		if err != nil {
+			continue
+		}
+		if err := dev.Configure(); err != nil {
+			dev.Close()
+			continue
+		}
+
+		storageIDs, err := dev.GetStorageIDs()
+		if err != nil {
+			dev.Close()
+			continue
+		}
+
+		var matched uint32
+		found := false
+		for _, id := range storageIDs.IDs {
+			if info, err := dev.GetStorageInfo(id); err == nil && info.StorageDescription == name {
+				matched, found = id, true
+				break
+			}
+		}
+		if !found {
+			dev.Close()
+			continue
+		}
+
+		if err := dev.OpenSession(); err != nil {
+			dev.Close()
+			return nil, fmt.Errorf("mtptarget: opening session on %q: %w", name, err)
+		}
+
+		return &Target{dev: dev, storageID: matched, dirs: map[string]uint32{}}, nil
+	}
+
+	return nil, fmt.Errorf("mtptarget: no MTP storage named %q found", name)
+}
+
+// Target implements migrator.Target by issuing MTP operations instead of
+// touching a local filesystem. Paths are modeled as object names nested
+// under a single storage; folders are created as MTP "association"
+// objects and cached by relative path so repeated MkdirAll calls for the
+// same directory don't recreate it.
+type Target struct {
+	dev       *gomtp.Device
+	storageID uint32
+
+	mu   sync.Mutex
+	dirs map[string]uint32 // relative dir path -> object ID
+}
+
+// Close ends the MTP session. It is not part of migrator.Target; call it
+// once a migration using this Target has finished.
+func (t *Target) Close() {
+	t.dev.CloseSession()
+	t.dev.Close()
+}
+
+// MkdirAll ensures every path component down to p exists as an MTP
+// folder object, creating any that don't.
+func (t *Target) MkdirAll(p string, _ os.FileMode) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.mkdirAllLocked(p)
+	return err
+}
+
+func (t *Target) mkdirAllLocked(p string) (uint32, error) {
+	p = path.Clean(p)
+	if p == "." || p == "/" {
+		return 0, nil
+	}
+	if id, ok := t.dirs[p]; ok {
+		return id, nil
+	}
+
+	parentID, err := t.mkdirAllLocked(path.Dir(p))
+	if err != nil {
+		return 0, err
+	}
+
+	info := gomtp.ObjectInfo{
+		StorageID:    t.storageID,
+		ObjectFormat: gomtp.OFC_Association,
+		ParentObject: parentID,
+		Filename:     path.Base(p),
+	}
+	_, _, objID, err := t.dev.SendObjectInfo(t.storageID, parentID, &info)
+	if err != nil {
+		return 0, fmt.Errorf("mtptarget: create folder %q: %w", p, err)
+	}
+	t.dirs[p] = objID
+	return objID, nil
+}
+
+// Create starts a new MTP object at p and returns a writer that uploads
+// it on Close — SendObject needs the final size up front, so writes are
+// buffered in memory until then.
+func (t *Target) Create(p string) (io.WriteCloser, error) {
+	t.mu.Lock()
+	parentID, err := t.mkdirAllLocked(path.Dir(p))
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &objectWriter{target: t, parentID: parentID, name: path.Base(p)}, nil
+}
+
+// objectWriter buffers a file's bytes until Close, when its size is
+// finally known and SendObjectInfo/SendObject can run.
+type objectWriter struct {
+	target   *Target
+	parentID uint32
+	name     string
+	buf      bytes.Buffer
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *objectWriter) Close() error {
+	info := gomtp.ObjectInfo{
+		StorageID:      w.target.storageID,
+		ObjectFormat:   gomtp.OFC_Undefined,
+		ParentObject:   w.parentID,
+		Filename:       w.name,
+		CompressedSize: uint32(w.buf.Len()),
+	}
+	_, _, _, err := w.target.dev.SendObjectInfo(w.target.storageID, w.parentID, &info)
+	if err != nil {
+		return fmt.Errorf("mtptarget: send object info for %q: %w", w.name, err)
+	}
+	if err := w.target.dev.SendObject(&w.buf, int64(w.buf.Len())); err != nil {
+		return fmt.Errorf("mtptarget: upload %q: %w", w.name, err)
+	}
+	return nil
+}
+
+// Open downloads the object at p into memory and returns a reader over
+// it.
+func (t *Target) Open(p string) (io.ReadCloser, error) {
+	handle, err := t.find(p)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.dev.GetObject(handle, &buf); err != nil {
+		return nil, fmt.Errorf("mtptarget: download %q: %w", p, err)
+	}
+	return io.NopCloser(&buf), nil
+}
+
+// Stat returns file info synthesized from the object's MTP metadata.
+func (t *Target) Stat(p string) (os.FileInfo, error) {
+	handle, err := t.find(p)
+	if err != nil {
+		return nil, err
+	}
+	info, err := t.dev.GetObjectInfo(handle)
+	if err != nil {
+		return nil, fmt.Errorf("mtptarget: stat %q: %w", p, err)
+	}
+	return fileInfo{name: path.Base(p), size: int64(info.CompressedSize)}, nil
+}
+
+// Chmod is a no-op: MTP objects have no POSIX mode to set.
+func (t *Target) Chmod(p string, mode os.FileMode) error { return nil }
+
+// Remove deletes the object at p.
+func (t *Target) Remove(p string) error {
+	handle, err := t.find(p)
+	if err != nil {
+		return err
+	}
+	if err := t.dev.DeleteObject(handle); err != nil {
+		return fmt.Errorf("mtptarget: delete %q: %w", p, err)
+	}
+	return nil
+}
+
+// find resolves p to an MTP object handle by listing the handles under
+// its parent directory and matching by filename.
+func (t *Target) find(p string) (uint32, error) {
+	t.mu.Lock()
+	parentID, err := t.mkdirAllLocked(path.Dir(p))
+	t.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	handles, err := t.dev.GetObjectHandles(t.storageID, 0, parentID)
+	if err != nil {
+		return 0, fmt.Errorf("mtptarget: list %q: %w", path.Dir(p), err)
+	}
+	name := path.Base(p)
+	for _, h := range handles.Handles {
+		if info, err := t.dev.GetObjectInfo(h); err == nil && info.Filename == name {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("mtptarget: %q not found", p)
+}
+
+// fileInfo is a minimal os.FileInfo backing Stat.
+type fileInfo struct {
+	name string
+	size int64
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) Mode() os.FileMode  { return 0644 }
+func (f fileInfo) ModTime() time.Time { return time.Time{} }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,36 @@
+//go:build !mtp
+
+package mtptarget
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotBuilt is returned by every function in this package when
+// HomeMigrate wasn't built with the "mtp" tag. MTP support pulls in
+// libusb via cgo, so it's opt-in rather than compiled by default.
+var ErrNotBuilt = errors.New("mtptarget: built without the \"mtp\" tag; MTP devices are unavailable")
+
+// List always returns ErrNotBuilt in this build; see mtptarget_mtp.go.
+func List() ([]Device, error) {
+	return nil, ErrNotBuilt
+}
+
+// Open always returns ErrNotBuilt in this build; see mtptarget_mtp.go.
+func Open(name string) (*Target, error) {
+	return nil, ErrNotBuilt
+}
+
+// Target is a placeholder so code written against the mtp-tagged API
+// (migrator.Target's method set) still type-checks without the tag. It
+// is never constructed in this build.
+type Target struct{}
+
+func (*Target) MkdirAll(path string, perm os.FileMode) error { return ErrNotBuilt }
+func (*Target) Create(path string) (io.WriteCloser, error)   { return nil, ErrNotBuilt }
+func (*Target) Open(path string) (io.ReadCloser, error)      { return nil, ErrNotBuilt }
+func (*Target) Stat(path string) (os.FileInfo, error)        { return nil, ErrNotBuilt }
+func (*Target) Chmod(path string, mode os.FileMode) error    { return ErrNotBuilt }
+func (*Target) Remove(path string) error                     { return ErrNotBuilt }
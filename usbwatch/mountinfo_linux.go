@@ -0,0 +1,119 @@
+//go:build linux
+
+package usbwatch
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// waitForMountpoint polls /proc/self/mountinfo, which the kernel flags with
+// POLLPRI on every mount table change, until devname
+// (e.g. "sda1") shows up as a mounted source, or until timeoutSeconds
+// elapses or ctx is cancelled. It checks immediately before waiting in
+// case the partition was already mounted by the time the add event was
+// processed.
+//
+// A udisks2-over-DBus lookup would cover removable media mounted outside
+// the root mount namespace (e.g. under a user session), but isn't wired
+// up here; this covers the common system-wide automount case.
+func waitForMountpoint(ctx context.Context, devname string, timeoutSeconds int) (string, bool) {
+	if mountpoint, ok := lookupMountpoint(devname); ok {
+		return mountpoint, true
+	}
+
+	mountinfo, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", false
+	}
+	defer mountinfo.Close()
+	fd := int(mountinfo.Fd())
+
+	// wakeR/wakeW let ctx cancellation interrupt the blocking Poll below
+	// immediately, instead of only being noticed on the next timeout or
+	// mount table change.
+	pipeFDs := make([]int, 2)
+	if err := unix.Pipe2(pipeFDs, unix.O_CLOEXEC); err != nil {
+		return "", false
+	}
+	wakeR, wakeW := pipeFDs[0], pipeFDs[1]
+	defer unix.Close(wakeR)
+
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-watchDone:
+		}
+		unix.Close(wakeW)
+	}()
+
+	deadline := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", false
+		}
+
+		pollFds := []unix.PollFd{
+			{Fd: int32(fd), Events: unix.POLLPRI},
+			{Fd: int32(wakeR), Events: unix.POLLIN},
+		}
+		n, err := unix.Poll(pollFds, int(remaining.Milliseconds()))
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return "", false
+		}
+		if n == 0 {
+			return "", false // timed out
+		}
+		if pollFds[1].Revents != 0 {
+			return "", false // ctx cancelled
+		}
+
+		if mountpoint, ok := lookupMountpoint(devname); ok {
+			return mountpoint, true
+		}
+	}
+}
+
+// lookupMountpoint scans /proc/self/mountinfo for a line whose source
+// device matches /dev/<devname>.
+func lookupMountpoint(devname string) (string, bool) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	want := "/dev/" + devname
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format: ID parentID major:minor root mountpoint options... - fstype source superopts
+		fields := strings.Fields(scanner.Text())
+		sepIdx := -1
+		for i, f := range fields {
+			if f == "-" {
+				sepIdx = i
+				break
+			}
+		}
+		if sepIdx < 0 || sepIdx+2 >= len(fields) {
+			continue
+		}
+		source := fields[sepIdx+2]
+		mountpoint := fields[4]
+		if source == want {
+			return mountpoint, true
+		}
+	}
+	return "", false
+}
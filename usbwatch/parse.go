@@ -0,0 +1,60 @@
+package usbwatch
+
+import "bytes"
+
+// parseUevent decodes a single NETLINK_KOBJECT_UEVENT payload. Messages
+// look like:
+//
+//	add@/devices/.../sda1\0ACTION=add\0DEVPATH=/devices/.../sda1\0
+//	SUBSYSTEM=block\0DEVNAME=sda1\0ID_FS_TYPE=ext4\0...
+//
+// with fields separated by NUL bytes. parseUevent reports false for
+// messages it can't make sense of (e.g. the libudev monitor's own
+// duplicate broadcast, which is prefixed with "libudev" instead of the
+// ACTION@DEVPATH header).
+func parseUevent(msg []byte) (kobjectEvent, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) == 0 || len(fields[0]) == 0 {
+		return kobjectEvent{}, false
+	}
+
+	header := fields[0]
+	if !bytes.Contains(header, []byte("@")) {
+		// Not a kernel uevent (e.g. a libudev-internal message).
+		return kobjectEvent{}, false
+	}
+
+	var ev kobjectEvent
+	for _, field := range fields[1:] {
+		key, value, ok := cutField(field)
+		if !ok {
+			continue
+		}
+		switch string(key) {
+		case "ACTION":
+			ev.action = string(value)
+		case "DEVPATH":
+			ev.devpath = string(value)
+		case "SUBSYSTEM":
+			ev.subsystem = string(value)
+		case "DEVNAME":
+			ev.devname = string(value)
+		case "ID_FS_TYPE":
+			ev.idFSType = string(value)
+		}
+	}
+
+	if ev.action == "" || ev.devpath == "" {
+		return kobjectEvent{}, false
+	}
+	return ev, true
+}
+
+// cutField splits a "KEY=VALUE" uevent field on its first '='.
+func cutField(field []byte) (key, value []byte, ok bool) {
+	i := bytes.IndexByte(field, '=')
+	if i < 0 {
+		return nil, nil, false
+	}
+	return field[:i], field[i+1:], true
+}
@@ -0,0 +1,75 @@
+package usbwatch
+
+import "testing"
+
+func uevent(fields ...string) []byte {
+	var msg []byte
+	for _, f := range fields {
+		msg = append(msg, []byte(f)...)
+		msg = append(msg, 0)
+	}
+	return msg
+}
+
+func TestParseUeventAdd(t *testing.T) {
+	msg := uevent(
+		"add@/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/host0/target0:0:0/0:0:0:0/block/sda/sda1",
+		"ACTION=add",
+		"DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-1/1-1:1.0/host0/target0:0:0/0:0:0:0/block/sda/sda1",
+		"SUBSYSTEM=block",
+		"DEVNAME=sda1",
+		"ID_FS_TYPE=vfat",
+	)
+
+	ev, ok := parseUevent(msg)
+	if !ok {
+		t.Fatal("expected parseUevent to succeed")
+	}
+	if ev.action != "add" || ev.subsystem != "block" || ev.devname != "sda1" || ev.idFSType != "vfat" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+func TestParseUeventRemove(t *testing.T) {
+	msg := uevent(
+		"remove@/devices/.../block/sda/sda1",
+		"ACTION=remove",
+		"DEVPATH=/devices/.../block/sda/sda1",
+		"SUBSYSTEM=block",
+		"DEVNAME=sda1",
+	)
+
+	ev, ok := parseUevent(msg)
+	if !ok {
+		t.Fatal("expected parseUevent to succeed")
+	}
+	if ev.action != "remove" || ev.devname != "sda1" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
+
+func TestParseUeventIgnoresNonBlock(t *testing.T) {
+	msg := uevent(
+		"add@/devices/.../net/eth0",
+		"ACTION=add",
+		"DEVPATH=/devices/.../net/eth0",
+		"SUBSYSTEM=net",
+	)
+
+	ev, ok := parseUevent(msg)
+	if !ok {
+		t.Fatal("expected parseUevent to succeed")
+	}
+	if ev.subsystem != "net" {
+		t.Fatalf("expected subsystem net, got %q", ev.subsystem)
+	}
+}
+
+func TestParseUeventRejectsGarbage(t *testing.T) {
+	if _, ok := parseUevent([]byte("libudev\x00garbage")); ok {
+		t.Fatal("expected parseUevent to reject a non-kernel message")
+	}
+	if _, ok := parseUevent(nil); ok {
+		t.Fatal("expected parseUevent to reject an empty message")
+	}
+}
@@ -0,0 +1,23 @@
+// Package usbwatch detects USB block device hotplug events without
+// polling, by subscribing to the kernel's udev netlink socket.
+package usbwatch
+
+// USBEvent describes a block device partition appearing or disappearing.
+type USBEvent struct {
+	// Path is the mountpoint of the partition. For Removed events this
+	// is the mountpoint it was previously mounted at.
+	Path string
+	// Removed is true when the kernel reported the device removed
+	// (unplugged). Unmounting a partition that stays plugged in does not
+	// produce a Removed event.
+	Removed bool
+}
+
+// kobjectEvent is a parsed NETLINK_KOBJECT_UEVENT message.
+type kobjectEvent struct {
+	action    string
+	devpath   string
+	subsystem string
+	devname   string
+	idFSType  string
+}
@@ -0,0 +1,176 @@
+//go:build linux
+
+package usbwatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountWaitTimeout bounds how long handleAdd waits for a freshly-added
+// partition to show up in /proc/self/mountinfo before giving up on it.
+const mountWaitTimeout = 30
+
+// Watcher subscribes to udev hotplug events over netlink and emits a
+// USBEvent once a partition is actually mounted (on add) or as soon as it
+// disappears (on remove).
+type Watcher struct {
+	fd     int
+	events chan USBEvent
+
+	mu      sync.Mutex
+	mounted map[string]string // DEVNAME -> last known mountpoint, for remove events
+	closed  bool
+
+	// addWG tracks in-flight handleAdd goroutines, so readLoop doesn't
+	// close events until every one of them is done sending on it.
+	addWG sync.WaitGroup
+
+	// waitForMount is waitForMountpoint by default; tests override it so
+	// handleAdd can be exercised without a real mount event.
+	waitForMount func(ctx context.Context, devname string, timeoutSeconds int) (string, bool)
+}
+
+// NewWatcher creates a Watcher. Call Start to begin receiving events.
+func NewWatcher() *Watcher {
+	return &Watcher{
+		events:       make(chan USBEvent, 16),
+		mounted:      make(map[string]string),
+		waitForMount: waitForMountpoint,
+	}
+}
+
+// Events returns the channel USBEvents are delivered on. It is closed
+// once the Watcher's read loop exits (typically because ctx was
+// cancelled or Close was called).
+func (w *Watcher) Events() <-chan USBEvent {
+	return w.events
+}
+
+// Start opens the netlink socket, subscribes to the kobject-uevent
+// multicast group, and begins dispatching USBEvents in a background
+// goroutine. It returns once the socket is bound; events keep flowing
+// until ctx is cancelled or Close is called.
+func (w *Watcher) Start(ctx context.Context) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return fmt.Errorf("usbwatch: opening netlink socket: %w", err)
+	}
+
+	addr := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 2, Pid: uint32(os.Getpid())}
+	if err := unix.Bind(fd, addr); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("usbwatch: binding netlink socket: %w", err)
+	}
+
+	w.fd = fd
+	go w.readLoop(ctx)
+
+	go func() {
+		<-ctx.Done()
+		w.Close()
+	}()
+
+	return nil
+}
+
+// Close stops the read loop and releases the netlink socket.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return unix.Close(w.fd)
+}
+
+func (w *Watcher) readLoop(ctx context.Context) {
+	// Wait for every in-flight handleAdd goroutine to finish sending
+	// before closing events, so a handleAdd that's still waiting on a
+	// mount can't send on a channel this defer already closed.
+	defer func() {
+		w.addWG.Wait()
+		close(w.events)
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := unix.Recvfrom(w.fd, buf, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Println("usbwatch: recvfrom:", err)
+			return
+		}
+
+		w.dispatch(ctx, buf[:n])
+	}
+}
+
+// dispatch parses a single raw netlink message and routes it to
+// handleAdd/handleRemove. It's split out from readLoop so tests can feed
+// it a recorded stream of synthetic uevent messages without an actual
+// netlink socket.
+func (w *Watcher) dispatch(ctx context.Context, msg []byte) {
+	ev, ok := parseUevent(msg)
+	if !ok || ev.subsystem != "block" || ev.devname == "" {
+		return
+	}
+
+	switch ev.action {
+	case "add", "change":
+		w.addWG.Add(1)
+		go func() {
+			defer w.addWG.Done()
+			w.handleAdd(ctx, ev)
+		}()
+	case "remove":
+		w.handleRemove(ev)
+	}
+}
+
+func (w *Watcher) handleAdd(ctx context.Context, ev kobjectEvent) {
+	mountpoint, ok := w.waitForMount(ctx, ev.devname, mountWaitTimeout)
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.mounted[ev.devname] = mountpoint
+	w.mu.Unlock()
+
+	select {
+	case w.events <- USBEvent{Path: mountpoint, Removed: false}:
+	case <-ctx.Done():
+	}
+}
+
+func (w *Watcher) handleRemove(ev kobjectEvent) {
+	w.mu.Lock()
+	mountpoint, known := w.mounted[ev.devname]
+	delete(w.mounted, ev.devname)
+	closed := w.closed
+	w.mu.Unlock()
+
+	if !known || closed {
+		return
+	}
+
+	select {
+	case w.events <- USBEvent{Path: mountpoint, Removed: true}:
+	default:
+		// Removal is best-effort; don't block the read loop on a full
+		// channel when the consumer has fallen behind.
+	}
+}
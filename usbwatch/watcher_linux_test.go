@@ -0,0 +1,163 @@
+//go:build linux
+
+package usbwatch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestWatcher returns a Watcher whose mount wait is stubbed to mount as
+// devname immediately, so handleAdd can be exercised without a real mount
+// event or the full mountWaitTimeout.
+func newTestWatcher() *Watcher {
+	w := NewWatcher()
+	w.waitForMount = func(ctx context.Context, devname string, timeoutSeconds int) (string, bool) {
+		return "/media/" + devname, true
+	}
+	return w
+}
+
+func recvEvent(t *testing.T, w *Watcher) USBEvent {
+	t.Helper()
+	select {
+	case ev := <-w.events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for USBEvent")
+		return USBEvent{}
+	}
+}
+
+func TestDispatchAddEmitsMountEventAndRecordsIt(t *testing.T) {
+	w := newTestWatcher()
+
+	w.dispatch(context.Background(), uevent(
+		"add@/devices/.../block/sda/sda1",
+		"ACTION=add",
+		"DEVPATH=/devices/.../block/sda/sda1",
+		"SUBSYSTEM=block",
+		"DEVNAME=sda1",
+	))
+
+	ev := recvEvent(t, w)
+	if ev.Removed || ev.Path != "/media/sda1" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	w.mu.Lock()
+	mountpoint, known := w.mounted["sda1"]
+	w.mu.Unlock()
+	if !known || mountpoint != "/media/sda1" {
+		t.Fatalf("expected sda1 to be recorded as mounted at /media/sda1, got %q, known=%v", mountpoint, known)
+	}
+}
+
+func TestDispatchRemoveEmitsEventAndClearsMountedEntry(t *testing.T) {
+	w := newTestWatcher()
+
+	w.dispatch(context.Background(), uevent(
+		"add@/devices/.../block/sda/sda1",
+		"ACTION=add", "DEVPATH=/devices/.../block/sda/sda1",
+		"SUBSYSTEM=block", "DEVNAME=sda1",
+	))
+	recvEvent(t, w)
+
+	w.dispatch(context.Background(), uevent(
+		"remove@/devices/.../block/sda/sda1",
+		"ACTION=remove", "DEVPATH=/devices/.../block/sda/sda1",
+		"SUBSYSTEM=block", "DEVNAME=sda1",
+	))
+
+	ev := recvEvent(t, w)
+	if !ev.Removed || ev.Path != "/media/sda1" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+
+	w.mu.Lock()
+	_, known := w.mounted["sda1"]
+	w.mu.Unlock()
+	if known {
+		t.Fatal("expected sda1 to be cleared from the mounted map after remove")
+	}
+}
+
+func TestHandleRemoveIgnoresUnknownDevice(t *testing.T) {
+	w := newTestWatcher()
+
+	// No prior add for sda1, so this remove should be a no-op rather
+	// than emit a bogus event — this is the dedup guard against
+	// duplicate/unsolicited remove uevents.
+	w.handleRemove(kobjectEvent{action: "remove", devname: "sda1"})
+
+	select {
+	case ev := <-w.events:
+		t.Fatalf("expected no event for an unknown device, got %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventsIsNotClosedWhileAddIsInFlight(t *testing.T) {
+	w := NewWatcher()
+	release := make(chan struct{})
+	w.waitForMount = func(ctx context.Context, devname string, timeoutSeconds int) (string, bool) {
+		<-release
+		return "/media/" + devname, true
+	}
+
+	w.dispatch(context.Background(), uevent(
+		"add@/devices/.../block/sda/sda1",
+		"ACTION=add", "DEVPATH=/devices/.../block/sda/sda1",
+		"SUBSYSTEM=block", "DEVNAME=sda1",
+	))
+
+	// Mirrors what readLoop's defer does on shutdown: wait for in-flight
+	// handleAdd goroutines before closing events.
+	closed := make(chan struct{})
+	go func() {
+		w.addWG.Wait()
+		close(w.events)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("events was closed while a handleAdd goroutine was still in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events to close after handleAdd finished")
+	}
+
+	ev, ok := <-w.events
+	if !ok || ev.Path != "/media/sda1" {
+		t.Fatalf("expected handleAdd's event before the channel closed, got %+v, ok=%v", ev, ok)
+	}
+}
+
+func TestHandleRemoveIsBestEffortOnFullChannel(t *testing.T) {
+	w := newTestWatcher()
+	w.events = make(chan USBEvent) // unbuffered, nobody reading
+
+	w.mu.Lock()
+	w.mounted["sda1"] = "/media/sda1"
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.handleRemove(kobjectEvent{action: "remove", devname: "sda1"})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handleRemove blocked on a full channel instead of dropping the event")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package usbwatch
+
+import (
+	"context"
+	"fmt"
+)
+
+// Watcher is a no-op on non-Linux platforms: udev netlink hotplug
+// events are a Linux-only concept.
+type Watcher struct {
+	events chan USBEvent
+}
+
+// NewWatcher creates a Watcher. On this platform it never emits events.
+func NewWatcher() *Watcher {
+	return &Watcher{events: make(chan USBEvent)}
+}
+
+// Events returns the (always empty) event channel.
+func (w *Watcher) Events() <-chan USBEvent {
+	return w.events
+}
+
+// Start returns an error: udev hotplug watching requires Linux.
+func (w *Watcher) Start(ctx context.Context) error {
+	return fmt.Errorf("usbwatch: hotplug watching is only supported on Linux")
+}
+
+// Close is a no-op.
+func (w *Watcher) Close() error {
+	close(w.events)
+	return nil
+}